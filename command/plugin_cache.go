@@ -0,0 +1,160 @@
+package command
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/hashicorp/hcl"
+)
+
+// PluginCache is a content-addressed store of provider plugin binaries,
+// keyed by the hex SHA256 digest recorded in providers.json. Meta consults
+// a configured PluginCache before falling back to downloading a plugin from
+// its origin registry, and stores newly-downloaded plugins back into it so
+// that other callers sharing the same cache don't need to hit the registry
+// at all.
+//
+// A cache may be a shared, mutable store (an S3 or GCS bucket writable by a
+// whole CI fleet), so a Get implementation must hash what it retrieves and
+// refuse to return an entry whose content doesn't match the requested
+// digest -- see verifyCachedPluginDigest.
+type PluginCache interface {
+	// Get retrieves the plugin with the given digest into dst, returning
+	// false if the cache has no such entry, and an error (with dst removed)
+	// if an entry exists but its content doesn't hash to digest.
+	Get(digest []byte, dst string) (bool, error)
+
+	// Put stores the plugin at src under the given digest.
+	Put(digest []byte, src string) error
+}
+
+// verifyCachedPluginDigest checks that the file at path actually hashes to
+// digest, removing it if not. Every PluginCache.Get implementation must
+// call this before reporting success, so that a tampered or poisoned entry
+// in a shared cache is never trusted as a verified plugin.
+func verifyCachedPluginDigest(digest []byte, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, copyErr := io.Copy(h, f)
+	f.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+
+	if got := h.Sum(nil); !bytes.Equal(got, digest) {
+		os.Remove(path)
+		return fmt.Errorf("cached plugin has digest %x, expected %x", got, digest)
+	}
+	return nil
+}
+
+// pluginCacheConfig is the shape of the "plugin_cache" block in the CLI
+// config file (~/.terraformrc). Exactly one of Dir, S3 or GCS is expected to
+// be set.
+type pluginCacheConfig struct {
+	PluginCache *struct {
+		Dir string `hcl:"dir"`
+
+		S3 *struct {
+			Bucket string `hcl:"bucket"`
+			Prefix string `hcl:"prefix"`
+			Region string `hcl:"region"`
+		} `hcl:"s3"`
+
+		GCS *struct {
+			Bucket string `hcl:"bucket"`
+			Prefix string `hcl:"prefix"`
+		} `hcl:"gcs"`
+	} `hcl:"plugin_cache"`
+}
+
+// pluginCache builds the PluginCache backend configured in the
+// "plugin_cache" block of the user's CLI config, if any. It returns nil if
+// no backend is configured, in which case callers should skip the cache and
+// go straight to the registry.
+func (m *Meta) pluginCache() PluginCache {
+	path, err := cliConfigFilePath()
+	if err != nil {
+		log.Printf("[WARNING] Could not determine CLI config file path: %s", err)
+		return nil
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var cfg pluginCacheConfig
+	if err := hcl.Decode(&cfg, string(buf)); err != nil {
+		log.Printf("[WARNING] Failed to parse plugin_cache config: %s", err)
+		return nil
+	}
+	if cfg.PluginCache == nil {
+		return nil
+	}
+
+	switch {
+	case cfg.PluginCache.S3 != nil:
+		s3cfg := cfg.PluginCache.S3
+		cache, err := newPluginCacheS3(s3cfg.Bucket, s3cfg.Prefix, s3cfg.Region)
+		if err != nil {
+			log.Printf("[WARNING] Failed to configure S3 plugin cache: %s", err)
+			return nil
+		}
+		return cache
+
+	case cfg.PluginCache.GCS != nil:
+		gcscfg := cfg.PluginCache.GCS
+		cache, err := newPluginCacheGCS(gcscfg.Bucket, gcscfg.Prefix)
+		if err != nil {
+			log.Printf("[WARNING] Failed to configure GCS plugin cache: %s", err)
+			return nil
+		}
+		return cache
+
+	case cfg.PluginCache.Dir != "":
+		return &pluginCacheFS{Dir: cfg.PluginCache.Dir}
+
+	default:
+		return nil
+	}
+}
+
+// getPlugin populates dst with the plugin matching digest, first consulting
+// the configured PluginCache (if any) and otherwise calling download to
+// fetch it from the origin registry. A plugin fetched from the registry is
+// written back into the cache so that other callers sharing it don't need
+// to hit the registry again.
+func (m *Meta) getPlugin(digest []byte, dst string, download func(dst string) error) error {
+	cache := m.pluginCache()
+
+	if cache != nil {
+		ok, err := cache.Get(digest, dst)
+		if err != nil {
+			log.Printf("[WARNING] Failed to read plugin %x from cache: %s", digest, err)
+		} else if ok {
+			return nil
+		}
+	}
+
+	if err := download(dst); err != nil {
+		return err
+	}
+
+	if cache != nil {
+		if err := cache.Put(digest, dst); err != nil {
+			log.Printf("[WARNING] Failed to store plugin %x in cache: %s", digest, err)
+		}
+	}
+
+	return nil
+}
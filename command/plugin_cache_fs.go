@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// pluginCacheFS is a PluginCache backed by a local directory, keyed by the
+// hex SHA256 digest of each plugin. This is the original behavior of the
+// plugin directory before PluginCache existed, expressed as one of its
+// implementations.
+type pluginCacheFS struct {
+	Dir string
+}
+
+func (c *pluginCacheFS) path(digest []byte) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("%x", digest))
+}
+
+func (c *pluginCacheFS) Get(digest []byte, dst string) (bool, error) {
+	src := c.path(digest)
+	if _, err := os.Stat(src); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err := copyPluginFile(src, dst); err != nil {
+		return false, err
+	}
+	if err := verifyCachedPluginDigest(digest, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *pluginCacheFS) Put(digest []byte, src string) error {
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	return copyPluginFile(src, c.path(digest))
+}
+
+func copyPluginFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Chmod(0755)
+}
@@ -0,0 +1,98 @@
+package command
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPluginCacheFS_roundTrip(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "terraform-plugin-cache")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	srcDir, err := ioutil.TempDir("", "terraform-plugin-src")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	content := []byte("pretend this is a plugin binary")
+	digest := sha256.Sum256(content)
+
+	src := filepath.Join(srcDir, "terraform-provider-test")
+	if err := ioutil.WriteFile(src, content, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &pluginCacheFS{Dir: cacheDir}
+	if err := c.Put(digest[:], src); err != nil {
+		t.Fatalf("Put failed: %s", err)
+	}
+
+	dst := filepath.Join(srcDir, "restored")
+	ok, err := c.Get(digest[:], dst)
+	if err != nil {
+		t.Fatalf("Get failed: %s", err)
+	}
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(got) != string(content) {
+		t.Fatalf("got %q, want %q", got, content)
+	}
+}
+
+func TestPluginCacheFS_missRejectsTamperedEntry(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "terraform-plugin-cache")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	srcDir, err := ioutil.TempDir("", "terraform-plugin-src")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	content := []byte("pretend this is a plugin binary")
+	digest := sha256.Sum256(content)
+
+	src := filepath.Join(srcDir, "terraform-provider-test")
+	if err := ioutil.WriteFile(src, content, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &pluginCacheFS{Dir: cacheDir}
+	if err := c.Put(digest[:], src); err != nil {
+		t.Fatalf("Put failed: %s", err)
+	}
+
+	// Simulate a poisoned shared cache: the object at this digest's key no
+	// longer actually hashes to that digest.
+	if err := ioutil.WriteFile(c.path(digest[:]), []byte("tampered content"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := filepath.Join(srcDir, "restored")
+	ok, err := c.Get(digest[:], dst)
+	if err == nil {
+		t.Fatal("expected Get to reject content that doesn't match the requested digest")
+	}
+	if ok {
+		t.Fatal("expected Get to report a miss/failure, not success, for tampered content")
+	}
+	if _, statErr := os.Stat(dst); !os.IsNotExist(statErr) {
+		t.Fatal("expected the tampered destination file to be removed")
+	}
+}
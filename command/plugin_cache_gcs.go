@@ -0,0 +1,81 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// pluginCacheGCS is a PluginCache backed by a GCS bucket, keyed by the hex
+// SHA256 digest of each plugin.
+type pluginCacheGCS struct {
+	Bucket string
+	Prefix string
+	Client *storage.Client
+}
+
+func newPluginCacheGCS(bucket, prefix string) (*pluginCacheGCS, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return &pluginCacheGCS{
+		Bucket: bucket,
+		Prefix: prefix,
+		Client: client,
+	}, nil
+}
+
+func (c *pluginCacheGCS) object(digest []byte) string {
+	return fmt.Sprintf("%s%x", c.Prefix, digest)
+}
+
+func (c *pluginCacheGCS) Get(digest []byte, dst string) (bool, error) {
+	r, err := c.Client.Bucket(c.Bucket).Object(c.object(digest)).NewReader(context.Background())
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return false, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Chmod(0755); err != nil {
+		f.Close()
+		return false, err
+	}
+	f.Close()
+
+	if err := verifyCachedPluginDigest(digest, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *pluginCacheGCS) Put(digest []byte, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := c.Client.Bucket(c.Bucket).Object(c.object(digest)).NewWriter(context.Background())
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// pluginCacheS3 is a PluginCache backed by an S3 bucket, keyed by the hex
+// SHA256 digest of each plugin.
+type pluginCacheS3 struct {
+	Bucket string
+	Prefix string
+	Client *s3.S3
+}
+
+func newPluginCacheS3(bucket, prefix, region string) (*pluginCacheS3, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pluginCacheS3{
+		Bucket: bucket,
+		Prefix: prefix,
+		Client: s3.New(sess),
+	}, nil
+}
+
+func (c *pluginCacheS3) key(digest []byte) string {
+	return fmt.Sprintf("%s%x", c.Prefix, digest)
+}
+
+func (c *pluginCacheS3) Get(digest []byte, dst string) (bool, error) {
+	out, err := c.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(c.key(digest)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound") {
+			return false, nil
+		}
+		return false, err
+	}
+	defer out.Body.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := io.Copy(f, out.Body); err != nil {
+		f.Close()
+		return false, err
+	}
+	if err := f.Chmod(0755); err != nil {
+		f.Close()
+		return false, err
+	}
+	f.Close()
+
+	if err := verifyCachedPluginDigest(digest, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *pluginCacheS3) Put(digest []byte, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = c.Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(c.key(digest)),
+		Body:   f,
+	})
+	return err
+}
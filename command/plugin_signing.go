@@ -0,0 +1,109 @@
+package command
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl"
+)
+
+// pluginSigningKeyring holds the trusted keys used to verify providers.json
+// when we read it, and the local signing key -- if any -- used to re-sign it
+// when we write an updated copy.
+//
+// RootKeys are long-lived and configured once by an operator; a manifest may
+// additionally delegate trust to other keys on a per-registry-host basis, as
+// recorded directly in its own "keys" section and validated against these
+// roots at verification time.
+type pluginSigningKeyring struct {
+	RootKeys map[string]ed25519.PublicKey
+
+	SigningKeyID string
+	SigningKey   ed25519.PrivateKey
+}
+
+// pluginSigningConfig is the shape of the "plugin_signing" block in the CLI
+// config file (~/.terraformrc), used to populate a pluginSigningKeyring.
+type pluginSigningConfig struct {
+	PluginSigning *struct {
+		RootKeys []struct {
+			KeyID     string `hcl:"key_id"`
+			PublicKey string `hcl:"public_key"`
+		} `hcl:"root_key"`
+		SigningKeyID string `hcl:"signing_key_id"`
+		SigningKey   string `hcl:"signing_key"`
+	} `hcl:"plugin_signing"`
+}
+
+// pluginSigningKeyring builds the keyring used to verify, and if possible
+// sign, the local plugin lock file, based on the "plugin_signing" block of
+// the user's CLI config. A missing config file, or one with no such block,
+// yields an empty keyring, which causes the lock file to be treated as
+// unsigned rather than rejected.
+func (m *Meta) pluginSigningKeyring() *pluginSigningKeyring {
+	keyring := &pluginSigningKeyring{
+		RootKeys: make(map[string]ed25519.PublicKey),
+	}
+
+	path, err := cliConfigFilePath()
+	if err != nil {
+		log.Printf("[WARNING] Could not determine CLI config file path: %s", err)
+		return keyring
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return keyring
+	}
+
+	var cfg pluginSigningConfig
+	if err := hcl.Decode(&cfg, string(buf)); err != nil {
+		log.Printf("[WARNING] Failed to parse plugin_signing config: %s", err)
+		return keyring
+	}
+	if cfg.PluginSigning == nil {
+		return keyring
+	}
+
+	for _, rk := range cfg.PluginSigning.RootKeys {
+		raw, err := hex.DecodeString(rk.PublicKey)
+		if err != nil {
+			log.Printf("[WARNING] Invalid root key %q in CLI config: %s", rk.KeyID, err)
+			continue
+		}
+		keyring.RootKeys[rk.KeyID] = ed25519.PublicKey(raw)
+	}
+
+	if cfg.PluginSigning.SigningKey != "" {
+		raw, err := hex.DecodeString(cfg.PluginSigning.SigningKey)
+		if err != nil {
+			log.Printf("[WARNING] Invalid local signing key in CLI config: %s", err)
+		} else {
+			keyring.SigningKeyID = cfg.PluginSigning.SigningKeyID
+			keyring.SigningKey = ed25519.PrivateKey(raw)
+		}
+	}
+
+	return keyring
+}
+
+// cliConfigFilePath returns the path to the user's CLI config file, the
+// same ~/.terraformrc (or $TERRAFORM_CONFIG) already used for provider
+// installation settings.
+func cliConfigFilePath() (string, error) {
+	if path := os.Getenv("TERRAFORM_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(u.HomeDir, ".terraformrc"), nil
+}
@@ -1,29 +1,136 @@
 package command
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 )
 
 func (m *Meta) providerPluginsLock() *pluginSHA256LockFile {
 	return &pluginSHA256LockFile{
 		Filename: filepath.Join(m.pluginDir(), "providers.json"),
+		Keyring:  m.pluginSigningKeyring(),
 	}
 }
 
+// pluginSHA256LockFile is our pinned digests for each plugin we've seen,
+// represented on disk as a signed manifest (see pluginManifest) rather than
+// a bare map, so that a tampered copy -- for example in a shared CI cache --
+// can be detected before its digests are trusted.
 type pluginSHA256LockFile struct {
 	Filename string
+	Keyring  *pluginSigningKeyring
 }
 
-// Read loads the lock information from the file and returns it. If the file
-// cannot be read, an empty map is returned to indicate that _no_ providers
-// are acceptable, since the user must run "terraform init" to lock some
-// providers before a context can be created.
-func (pf *pluginSHA256LockFile) Read() map[string][]byte {
+// ProviderLock is everything we've pinned for a single provider: the
+// version we resolved, the registry address we resolved it from, and the
+// SHA256 digest we've seen for each platform we've ever initialized against,
+// keyed by "os_arch" (e.g. "linux_amd64").
+//
+// Recording every platform a team or CI matrix has touched -- rather than
+// just the one "terraform init" last ran on -- lets a single committed lock
+// file satisfy the whole fleet; see also "terraform providers lock".
+type ProviderLock struct {
+	Version   string            `json:"version"`
+	Source    string            `json:"source"`
+	Platforms map[string]string `json:"platforms"`
+}
+
+// digestForPlatform returns the raw SHA256 digest pinned for the given
+// "os_arch" platform, if any.
+func (l *ProviderLock) digestForPlatform(platform string) ([]byte, bool) {
+	strDigest, ok := l.Platforms[platform]
+	if !ok {
+		return nil, false
+	}
+
+	var digest []byte
+	if _, err := fmt.Sscanf(strDigest, "%x", &digest); err != nil {
+		return nil, false
+	}
+	return digest, true
+}
+
+// delegatedKey grants signing authority over a particular provider registry
+// host to a key other than one of our root keys, for a bounded time. These
+// are recorded directly in the manifest so that a verifier only needs the
+// root keys to validate the whole chain.
+//
+// A delegation is itself a TUF-style signed statement: RootKeyID and
+// Signature authenticate Host/KeyID/PublicKey/Expires with a signature from
+// that root key, so an attacker with no root private key cannot simply add
+// an entry to Keys and have it trusted.
+type delegatedKey struct {
+	Host      string    `json:"host"`
+	KeyID     string    `json:"key_id"`
+	PublicKey string    `json:"public_key"` // hex-encoded ed25519 public key
+	Expires   time.Time `json:"expires"`
+
+	RootKeyID string `json:"root_key_id"`
+	Signature string `json:"signature"`
+}
+
+// signedBytes returns the canonical bytes a delegation's Signature is
+// computed over: everything about the delegation except the signature
+// itself.
+func (dk *delegatedKey) signedBytes() ([]byte, error) {
+	return json.Marshal(struct {
+		Host      string    `json:"host"`
+		KeyID     string    `json:"key_id"`
+		PublicKey string    `json:"public_key"`
+		Expires   time.Time `json:"expires"`
+	}{dk.Host, dk.KeyID, dk.PublicKey, dk.Expires})
+}
+
+// registryHost returns the registry hostname implied by a provider source
+// address such as "registry.terraform.io/hashicorp/aws".
+func registryHost(source string) string {
+	if i := strings.Index(source, "/"); i >= 0 {
+		return source[:i]
+	}
+	return source
+}
+
+// pluginManifest is the on-disk JSON structure of providers.json.
+type pluginManifest struct {
+	Providers map[string]*ProviderLock `json:"providers"`
+
+	// Keys lists any registry-host-scoped keys that a root key has
+	// delegated trust to, beyond the root keys configured locally by the
+	// operator; each entry carries that root key's signature.
+	Keys []delegatedKey `json:"keys,omitempty"`
+
+	// KeyID and Signature are populated when the manifest is signed: KeyID
+	// identifies which root or delegated key produced Signature, which is
+	// an ed25519 signature (hex-encoded) over the JSON encoding of
+	// Providers.
+	KeyID     string `json:"key_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// signedBytes returns the canonical bytes that a manifest's signature is
+// computed over. Key delegations are authenticated separately (see
+// delegatedKey.signedBytes): they are not folded into this signature, since
+// that would let an attacker holding no root key forge a delegation and
+// sign the forged manifest themselves.
+func (m *pluginManifest) signedBytes() ([]byte, error) {
+	return json.Marshal(m.Providers)
+}
+
+// Read loads the lock information from the file and returns it, one
+// ProviderLock per provider. If the file cannot be read, or it fails
+// signature verification against a trusted key, an empty map is returned to
+// indicate that _no_ providers are acceptable, since the user must run
+// "terraform init" to lock some providers before a context can be created.
+func (pf *pluginSHA256LockFile) Read() map[string]*ProviderLock {
 	// Returning an empty map is different than nil because it causes
 	// us to reject all plugins as uninitialized, rather than applying no
 	// constraints at all.
@@ -31,48 +138,213 @@ func (pf *pluginSHA256LockFile) Read() map[string][]byte {
 	// We don't surface any specific errors here because we want it to all
 	// roll up into our more-user-friendly error that appears when plugin
 	// constraint verification fails during context creation.
-	digests := make(map[string][]byte)
+	locks := make(map[string]*ProviderLock)
 
 	buf, err := ioutil.ReadFile(pf.Filename)
 	if err != nil {
 		// This is expected if the user runs any context-using command before
 		// running "terraform init".
 		log.Printf("[INFO] Failed to read plugin lock file %s: %s", pf.Filename, err)
-		return digests
+		return locks
 	}
 
-	var strDigests map[string]string
-	err = json.Unmarshal(buf, &strDigests)
+	var manifest pluginManifest
+	err = json.Unmarshal(buf, &manifest)
 	if err != nil {
 		// This should never happen unless the user directly edits the file.
 		log.Printf("[WARNING] Plugin lock file %s failed to parse as JSON: %s", pf.Filename, err)
-		return digests
+		return locks
 	}
 
-	for name, strDigest := range strDigests {
-		var digest []byte
-		_, err := fmt.Sscanf(strDigest, "%x", &digest)
-		if err == nil {
-			digests[name] = digest
-		} else {
-			// This should never happen unless the user directly edits the file.
-			log.Printf("[WARNING] Plugin lock file %s has invalid digest for %q", pf.Filename, name)
+	if err := pf.verify(&manifest); err != nil {
+		log.Printf("[WARNING] Plugin lock file %s failed signature verification: %s", pf.Filename, err)
+		return locks
+	}
+
+	for name, lock := range manifest.Providers {
+		if lock != nil {
+			locks[name] = lock
 		}
 	}
 
+	return locks
+}
+
+// ReadDigests loads the lock information from the file, like Read, but
+// returns just the SHA256 digest each provider pinned for the platform
+// Terraform is currently running on, which is what plugin verification at
+// context creation actually needs.
+func (pf *pluginSHA256LockFile) ReadDigests() map[string][]byte {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+
+	digests := make(map[string][]byte)
+	for name, lock := range pf.Read() {
+		digest, ok := lock.digestForPlatform(platform)
+		if !ok {
+			log.Printf("[WARNING] Plugin lock file %s has no digest for %q on %s", pf.Filename, name, platform)
+			continue
+		}
+		digests[name] = digest
+	}
 	return digests
 }
 
+// verify checks the manifest's signature, if any, against either one of our
+// configured root keys or a key the manifest itself delegates to for a
+// particular registry host, rejecting unknown or expired keys, delegations
+// that aren't themselves signed by a root key, and delegated signatures
+// over providers outside the delegation's host. If no root keys are
+// configured at all we skip verification entirely, so that existing
+// unsigned lock files keep working until an operator opts in.
+func (pf *pluginSHA256LockFile) verify(manifest *pluginManifest) error {
+	if pf.Keyring == nil || len(pf.Keyring.RootKeys) == 0 {
+		return nil
+	}
+	if manifest.Signature == "" {
+		return fmt.Errorf("manifest is not signed")
+	}
+
+	sig, err := hex.DecodeString(manifest.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %s", err)
+	}
+
+	key, delegation, err := pf.resolveKey(manifest, manifest.KeyID)
+	if err != nil {
+		return err
+	}
+
+	signed, err := manifest.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, signed, sig) {
+		return fmt.Errorf("signature does not match key %q", manifest.KeyID)
+	}
+
+	if delegation != nil {
+		for name, lock := range manifest.Providers {
+			if lock == nil {
+				continue
+			}
+			if host := registryHost(lock.Source); host != delegation.Host {
+				return fmt.Errorf("key %q is only delegated for host %q, but provider %q is sourced from %q", manifest.KeyID, delegation.Host, name, host)
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveKey looks up a key id first among our configured root keys and
+// then among the manifest's own delegated keys, returning the delegation
+// itself (nil for a root key) so the caller can enforce its host scope. A
+// delegated key is trusted only once its own delegation has been verified
+// against a root key; an expired or unauthenticated delegation is rejected.
+func (pf *pluginSHA256LockFile) resolveKey(manifest *pluginManifest, keyID string) (ed25519.PublicKey, *delegatedKey, error) {
+	if key, ok := pf.Keyring.RootKeys[keyID]; ok {
+		return key, nil, nil
+	}
+
+	for i := range manifest.Keys {
+		dk := &manifest.Keys[i]
+		if dk.KeyID != keyID {
+			continue
+		}
+		if !dk.Expires.IsZero() && time.Now().After(dk.Expires) {
+			return nil, nil, fmt.Errorf("delegated key %q for host %q expired at %s", dk.KeyID, dk.Host, dk.Expires)
+		}
+		if err := pf.verifyDelegation(dk); err != nil {
+			return nil, nil, err
+		}
+		raw, err := hex.DecodeString(dk.PublicKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("delegated key %q has invalid encoding: %s", dk.KeyID, err)
+		}
+		return ed25519.PublicKey(raw), dk, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown signing key %q", keyID)
+}
+
+// verifyDelegation checks that dk was itself signed by one of our
+// configured root keys, so that an attacker who controls no root private
+// key cannot forge a delegation to a key of their own choosing.
+func (pf *pluginSHA256LockFile) verifyDelegation(dk *delegatedKey) error {
+	rootKey, ok := pf.Keyring.RootKeys[dk.RootKeyID]
+	if !ok {
+		return fmt.Errorf("delegated key %q claims unknown root key %q", dk.KeyID, dk.RootKeyID)
+	}
+
+	sig, err := hex.DecodeString(dk.Signature)
+	if err != nil {
+		return fmt.Errorf("delegated key %q has invalid signature encoding: %s", dk.KeyID, err)
+	}
+
+	signed, err := dk.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(rootKey, signed, sig) {
+		return fmt.Errorf("delegation for key %q is not signed by root key %q", dk.KeyID, dk.RootKeyID)
+	}
+	return nil
+}
+
+// existingKeys loads the Keys (delegation) section from whatever manifest
+// is currently on disk, if any, so that Write can carry it forward instead
+// of dropping it when it rewrites the file with a fresh signature.
+func (pf *pluginSHA256LockFile) existingKeys() []delegatedKey {
+	buf, err := ioutil.ReadFile(pf.Filename)
+	if err != nil {
+		return nil
+	}
+
+	var manifest pluginManifest
+	if err := json.Unmarshal(buf, &manifest); err != nil {
+		return nil
+	}
+	return manifest.Keys
+}
+
 // Write persists lock information to disk, where it will be retrieved by
-// future calls to Read. This entirely replaces any previous lock information,
-// so the given map must be comprehensive.
-func (pf *pluginSHA256LockFile) Write(digests map[string][]byte) error {
-	strDigests := map[string]string{}
-	for name, digest := range digests {
-		strDigests[name] = fmt.Sprintf("%x", digest)
+// future calls to Read. This entirely replaces any previous lock
+// information, so the given map must be comprehensive. Any key delegations
+// already recorded in the file are carried forward unchanged -- Write only
+// ever replaces Providers and the top-level signature -- since otherwise
+// rewriting the file would silently drop them, and if our own signer is
+// itself a delegated key, would lock every provider out as soon as its own
+// delegation disappeared. If a local signing key is configured, the
+// manifest is (re-)signed so that Read (ours or a teammate's) can verify it
+// later.
+//
+// If verification is enabled (root keys are configured) but we have no
+// local signing key, we refuse to write: an unsigned manifest would fail
+// verify's "manifest is not signed" check on the very next Read, silently
+// locking every provider out.
+func (pf *pluginSHA256LockFile) Write(locks map[string]*ProviderLock) error {
+	manifest := &pluginManifest{
+		Providers: locks,
+		Keys:      pf.existingKeys(),
 	}
 
-	buf, err := json.MarshalIndent(strDigests, "", "  ")
+	switch {
+	case pf.Keyring != nil && pf.Keyring.SigningKey != nil:
+		signed, err := manifest.signedBytes()
+		if err != nil {
+			return fmt.Errorf("failed to serialize plugin lock as JSON: %s", err)
+		}
+		manifest.KeyID = pf.Keyring.SigningKeyID
+		manifest.Signature = hex.EncodeToString(ed25519.Sign(pf.Keyring.SigningKey, signed))
+
+	case pf.Keyring != nil && len(pf.Keyring.RootKeys) > 0:
+		return fmt.Errorf(
+			"plugin lock file verification is enabled (root keys are configured) but no local " +
+				"signing key is available to re-sign %s; configure plugin_signing.signing_key in " +
+				"the CLI config before running this command", pf.Filename)
+	}
+
+	buf, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
 		// should never happen
 		return fmt.Errorf("failed to serialize plugin lock as JSON: %s", err)
@@ -83,4 +355,4 @@ func (pf *pluginSHA256LockFile) Write(digests map[string][]byte) error {
 	) // ignore error since WriteFile below will generate a better one anyway
 
 	return ioutil.WriteFile(pf.Filename, buf, os.ModePerm)
-}
\ No newline at end of file
+}
@@ -0,0 +1,344 @@
+package command
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testPluginLockFile(t *testing.T, keyring *pluginSigningKeyring) (*pluginSHA256LockFile, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "terraform-plugins-lock")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	pf := &pluginSHA256LockFile{
+		Filename: filepath.Join(dir, "providers.json"),
+		Keyring:  keyring,
+	}
+	return pf, func() { os.RemoveAll(dir) }
+}
+
+func TestPluginSHA256LockFile_unsignedRoundTrip(t *testing.T) {
+	pf, cleanup := testPluginLockFile(t, nil)
+	defer cleanup()
+
+	want := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Version:   "2.0.0",
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+
+	if err := pf.Write(want); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+
+	got := pf.Read()
+	if len(got) != 1 || got["registry.terraform.io/hashicorp/aws"].Version != "2.0.0" {
+		t.Fatalf("unexpected locks read back: %#v", got)
+	}
+}
+
+func TestPluginSHA256LockFile_signedRoundTrip(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	keyring := &pluginSigningKeyring{
+		RootKeys:     map[string]ed25519.PublicKey{"root": rootPub},
+		SigningKeyID: "root",
+		SigningKey:   rootPriv,
+	}
+	pf, cleanup := testPluginLockFile(t, keyring)
+	defer cleanup()
+
+	want := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Version:   "2.0.0",
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+
+	if err := pf.Write(want); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+
+	got := pf.Read()
+	if len(got) != 1 {
+		t.Fatalf("expected a verified lock, got none: %#v", got)
+	}
+}
+
+func TestPluginSHA256LockFile_writeFailsWithoutSigningKey(t *testing.T) {
+	rootPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	keyring := &pluginSigningKeyring{
+		RootKeys: map[string]ed25519.PublicKey{"root": rootPub},
+	}
+	pf, cleanup := testPluginLockFile(t, keyring)
+	defer cleanup()
+
+	err = pf.Write(map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {Source: "registry.terraform.io/hashicorp/aws"},
+	})
+	if err == nil {
+		t.Fatal("expected Write to fail loudly rather than emit an unsigned manifest")
+	}
+}
+
+func TestPluginSHA256LockFile_rejectsForgedDelegation(t *testing.T) {
+	rootPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Attacker controls no root key, but can generate their own key pair
+	// and try to smuggle it into the manifest as a "delegation".
+	attackerPub, attackerPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	providers := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+	signedBytes, err := (&pluginManifest{Providers: providers}).signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &pluginManifest{
+		Providers: providers,
+		Keys: []delegatedKey{
+			{
+				Host:      "registry.terraform.io",
+				KeyID:     "attacker",
+				PublicKey: hex.EncodeToString(attackerPub),
+				Expires:   time.Now().Add(24 * time.Hour),
+				// No valid RootKeyID/Signature: this delegation was never
+				// actually authorized by a root key.
+			},
+		},
+		KeyID:     "attacker",
+		Signature: hex.EncodeToString(ed25519.Sign(attackerPriv, signedBytes)),
+	}
+
+	pf, cleanup := testPluginLockFile(t, &pluginSigningKeyring{
+		RootKeys: map[string]ed25519.PublicKey{"root": rootPub},
+	})
+	defer cleanup()
+	writeRawManifest(t, pf.Filename, manifest)
+
+	if got := pf.Read(); len(got) != 0 {
+		t.Fatalf("forged delegation was trusted: %#v", got)
+	}
+}
+
+func TestPluginSHA256LockFile_rejectsHostMismatch(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	delegatePub, delegatePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A legitimately root-signed delegation, but scoped to a different
+	// registry host than the provider it's about to sign for.
+	dk := delegatedKey{
+		Host:      "other.example.com",
+		KeyID:     "delegate",
+		PublicKey: hex.EncodeToString(delegatePub),
+		Expires:   time.Now().Add(24 * time.Hour),
+		RootKeyID: "root",
+	}
+	dkBytes, err := dk.signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dk.Signature = hex.EncodeToString(ed25519.Sign(rootPriv, dkBytes))
+
+	providers := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+	signedBytes, err := (&pluginManifest{Providers: providers}).signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &pluginManifest{
+		Providers: providers,
+		Keys:      []delegatedKey{dk},
+		KeyID:     "delegate",
+		Signature: hex.EncodeToString(ed25519.Sign(delegatePriv, signedBytes)),
+	}
+
+	pf, cleanup := testPluginLockFile(t, &pluginSigningKeyring{
+		RootKeys: map[string]ed25519.PublicKey{"root": rootPub},
+	})
+	defer cleanup()
+	writeRawManifest(t, pf.Filename, manifest)
+
+	if got := pf.Read(); len(got) != 0 {
+		t.Fatalf("out-of-scope delegated signature was trusted: %#v", got)
+	}
+}
+
+func TestPluginSHA256LockFile_rejectsExpiredDelegation(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	delegatePub, delegatePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dk := delegatedKey{
+		Host:      "registry.terraform.io",
+		KeyID:     "delegate",
+		PublicKey: hex.EncodeToString(delegatePub),
+		Expires:   time.Now().Add(-24 * time.Hour),
+		RootKeyID: "root",
+	}
+	dkBytes, err := dk.signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dk.Signature = hex.EncodeToString(ed25519.Sign(rootPriv, dkBytes))
+
+	providers := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+	signedBytes, err := (&pluginManifest{Providers: providers}).signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &pluginManifest{
+		Providers: providers,
+		Keys:      []delegatedKey{dk},
+		KeyID:     "delegate",
+		Signature: hex.EncodeToString(ed25519.Sign(delegatePriv, signedBytes)),
+	}
+
+	pf, cleanup := testPluginLockFile(t, &pluginSigningKeyring{
+		RootKeys: map[string]ed25519.PublicKey{"root": rootPub},
+	})
+	defer cleanup()
+	writeRawManifest(t, pf.Filename, manifest)
+
+	if got := pf.Read(); len(got) != 0 {
+		t.Fatalf("expired delegation was trusted: %#v", got)
+	}
+}
+
+// TestPluginSHA256LockFile_delegatedSignerRoundTrip covers the case where
+// our own local signing key is itself a delegated key, not a root key.
+// Write must carry the existing delegation forward from the file on disk
+// rather than dropping it, or the manifest it produces would have no Keys
+// entry to authenticate its own KeyID, locking every provider out on the
+// very next Read.
+func TestPluginSHA256LockFile_delegatedSignerRoundTrip(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	delegatePub, delegatePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dk := delegatedKey{
+		Host:      "registry.terraform.io",
+		KeyID:     "delegate",
+		PublicKey: hex.EncodeToString(delegatePub),
+		Expires:   time.Now().Add(24 * time.Hour),
+		RootKeyID: "root",
+	}
+	dkBytes, err := dk.signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	dk.Signature = hex.EncodeToString(ed25519.Sign(rootPriv, dkBytes))
+
+	initial := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Version:   "1.0.0",
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "aabbcc"},
+		},
+	}
+	signedBytes, err := (&pluginManifest{Providers: initial}).signedBytes()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	manifest := &pluginManifest{
+		Providers: initial,
+		Keys:      []delegatedKey{dk},
+		KeyID:     "delegate",
+		Signature: hex.EncodeToString(ed25519.Sign(delegatePriv, signedBytes)),
+	}
+
+	pf, cleanup := testPluginLockFile(t, &pluginSigningKeyring{
+		RootKeys:     map[string]ed25519.PublicKey{"root": rootPub},
+		SigningKeyID: "delegate",
+		SigningKey:   delegatePriv,
+	})
+	defer cleanup()
+	writeRawManifest(t, pf.Filename, manifest)
+
+	updated := map[string]*ProviderLock{
+		"registry.terraform.io/hashicorp/aws": {
+			Version:   "2.0.0",
+			Source:    "registry.terraform.io/hashicorp/aws",
+			Platforms: map[string]string{"linux_amd64": "ddeeff"},
+		},
+	}
+	if err := pf.Write(updated); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+
+	got := pf.Read()
+	if len(got) != 1 || got["registry.terraform.io/hashicorp/aws"].Version != "2.0.0" {
+		t.Fatalf("delegation did not survive Write; locks read back as %#v", got)
+	}
+}
+
+func writeRawManifest(t *testing.T, filename string, manifest *pluginManifest) {
+	t.Helper()
+
+	buf, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filename, buf, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
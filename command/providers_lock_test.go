@@ -0,0 +1,26 @@
+package command
+
+import "testing"
+
+func TestProviderDownloadMetadataURL(t *testing.T) {
+	cases := []struct {
+		source, version, os, arch string
+		want                      string
+	}{
+		{
+			"registry.terraform.io/hashicorp/aws", "2.0.0", "linux", "amd64",
+			"https://registry.terraform.io/v1/providers/hashicorp/aws/2.0.0/download/linux/amd64",
+		},
+		{
+			"my.registry.example.com/acme/widget", "1.2.3", "darwin", "arm64",
+			"https://my.registry.example.com/v1/providers/acme/widget/1.2.3/download/darwin/arm64",
+		},
+	}
+
+	for _, c := range cases {
+		got := providerDownloadMetadataURL(c.source, c.version, c.os, c.arch)
+		if got != c.want {
+			t.Errorf("providerDownloadMetadataURL(%q, %q, %q, %q) = %q, want %q", c.source, c.version, c.os, c.arch, got, c.want)
+		}
+	}
+}
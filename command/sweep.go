@@ -0,0 +1,93 @@
+package command
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+// SweepCommand is a Command implementation that runs every registered
+// acceptance-test sweeper, tearing down leftover infrastructure across one
+// or more regions. It's a developer/CI tool, not something end users of
+// Terraform configurations need.
+type SweepCommand struct {
+	Meta
+}
+
+func (c *SweepCommand) Run(args []string) int {
+	var regions, resourceTypes string
+	var dryRun bool
+	var parallel int
+
+	cmdFlags := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	cmdFlags.StringVar(&regions, "regions", "", "comma-separated list of regions to sweep")
+	cmdFlags.StringVar(&resourceTypes, "resource-types", "", "comma-separated list of sweeper name substrings to limit sweeping to")
+	cmdFlags.BoolVar(&dryRun, "dry-run", false, "report what would be swept without deleting anything")
+	cmdFlags.IntVar(&parallel, "parallel", 10, "number of regions to sweep concurrently per sweeper")
+	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	opts := resource.SweepRunOptions{
+		DryRun:   dryRun,
+		Parallel: parallel,
+	}
+	if regions != "" {
+		opts.Regions = strings.Split(regions, ",")
+	}
+	if resourceTypes != "" {
+		opts.ResourceTypes = strings.Split(resourceTypes, ",")
+	}
+
+	report, err := resource.RunSweepers(opts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error running sweepers: %s", err))
+		return 1
+	}
+
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error formatting sweep report: %s", err))
+		return 1
+	}
+	c.Ui.Output(string(buf))
+
+	for _, r := range report.Results {
+		if r.Status == "failed" {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (c *SweepCommand) Help() string {
+	helpText := `
+Usage: terraform sweep [options]
+
+  Runs every registered acceptance-test sweeper to remove leftover test
+  infrastructure.
+
+Options:
+
+  -regions=us-east-1,us-west-2    Only sweep the given regions, instead of
+                                   each sweeper's own default region list.
+
+  -resource-types=aws_key_pair     Only run sweepers whose name contains one
+                                   of the given substrings.
+
+  -dry-run                        Report what would be deleted without
+                                   deleting anything.
+
+  -parallel=N                     Sweep up to N regions concurrently per
+                                   sweeper (default 10).
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *SweepCommand) Synopsis() string {
+	return "Destroy leftover acceptance test infrastructure"
+}
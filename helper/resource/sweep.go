@@ -0,0 +1,56 @@
+package resource
+
+import "fmt"
+
+// SweeperFunc tears down leftover acceptance-test infrastructure in a single
+// region. It is responsible for building whatever provider client it needs
+// from region itself.
+type SweeperFunc func(region string) error
+
+// Sweeper registers a cleanup routine for acceptance-test leftovers, for
+// pickup by RunSweepers and the "terraform sweep" command built on it.
+//
+// Dependencies names other sweepers that must run -- and finish -- before
+// this one starts, so that e.g. ENIs and subnets are gone before we try to
+// tear down the VPC they live in.
+//
+// Regions lists the regions this sweeper supports. If empty, the regions
+// passed via SetDefaultSweeperRegions are used instead, so that most
+// sweepers don't need to repeat the fleet's region list.
+type Sweeper struct {
+	Name         string
+	Dependencies []string
+	Regions      []string
+	F            SweeperFunc
+}
+
+var sweeperFuncs = map[string]*Sweeper{}
+var sweeperDefaultRegions []string
+
+// AddTestSweepers registers a sweeper under name, so that it is picked up by
+// RunSweepers and the "terraform sweep" orchestrator. It
+// panics on a duplicate name, since that almost always indicates a
+// copy-pasted init().
+func AddTestSweepers(name string, s *Sweeper) {
+	if _, ok := sweeperFuncs[name]; ok {
+		panic(fmt.Sprintf("Error adding (%s) to sweeperFuncs: function already exists in map", name))
+	}
+
+	s.Name = name
+	sweeperFuncs[name] = s
+}
+
+// SetDefaultSweeperRegions sets the regions a sweeper runs against when it
+// declares none of its own.
+func SetDefaultSweeperRegions(regions []string) {
+	sweeperDefaultRegions = regions
+}
+
+// regions returns the regions this sweeper should run against: its own, if
+// it declared any, otherwise the fleet-wide default.
+func (s *Sweeper) regions() []string {
+	if len(s.Regions) > 0 {
+		return s.Regions
+	}
+	return sweeperDefaultRegions
+}
@@ -0,0 +1,228 @@
+package resource
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SweepRunOptions configures a single run of RunSweepers.
+type SweepRunOptions struct {
+	// Regions overrides every matched sweeper's own region list, so a
+	// caller can scope a run down to e.g. a single region under test.
+	Regions []string
+
+	// ResourceTypes, if non-empty, restricts the run to sweepers whose
+	// name contains one of these substrings.
+	ResourceTypes []string
+
+	// DryRun reports what would be swept without deleting anything.
+	DryRun bool
+
+	// Parallel is the number of regions to sweep concurrently per
+	// sweeper. Values less than 1 are treated as 1.
+	Parallel int
+}
+
+// SweepResult is the outcome of running one sweeper against one region.
+type SweepResult struct {
+	Sweeper string `json:"sweeper"`
+	Region  string `json:"region"`
+	Status  string `json:"status"` // "deleted", "skipped" or "failed"
+	Error   string `json:"error,omitempty"`
+}
+
+// SweepReport is the JSON-serializable output of a full sweep run.
+type SweepReport struct {
+	Results []SweepResult `json:"results"`
+}
+
+// RunSweepers runs every sweeper registered via AddTestSweepers that matches
+// opts.ResourceTypes, resolving dependency order so that a sweeper only
+// starts once every sweeper it depends on has finished in every region, and
+// fanning the remaining per-region work out across up to opts.Parallel
+// goroutines, retrying individual regions with backoff when AWS reports
+// rate limiting.
+func RunSweepers(opts SweepRunOptions) (*SweepReport, error) {
+	order, err := sweeperRunOrder(opts.ResourceTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	report := &SweepReport{}
+	var mu sync.Mutex
+
+	for _, name := range order {
+		s := sweeperFuncs[name]
+
+		regions := opts.Regions
+		if len(regions) == 0 {
+			regions = s.regions()
+		}
+
+		sem := make(chan struct{}, parallel)
+		var wg sync.WaitGroup
+
+		for _, region := range regions {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(region string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				result := SweepResult{Sweeper: s.Name, Region: region}
+
+				switch {
+				case opts.DryRun:
+					result.Status = "skipped"
+					log.Printf("[INFO] sweep: (dry-run) would run %s in %s", s.Name, region)
+				default:
+					if err := sweepWithBackoff(s, region); err != nil {
+						result.Status = "failed"
+						result.Error = err.Error()
+						log.Printf("[ERROR] sweep: %s in %s: %s", s.Name, region, err)
+					} else {
+						result.Status = "deleted"
+					}
+				}
+
+				mu.Lock()
+				report.Results = append(report.Results, result)
+				mu.Unlock()
+			}(region)
+		}
+
+		wg.Wait()
+	}
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		a, b := report.Results[i], report.Results[j]
+		if a.Sweeper != b.Sweeper {
+			return a.Sweeper < b.Sweeper
+		}
+		return a.Region < b.Region
+	})
+
+	return report, nil
+}
+
+// sweepWithBackoff retries a sweeper a handful of times with truncated
+// exponential backoff, since a fleet sweeping many regions concurrently is
+// likely to trip AWS API rate limiting.
+func sweepWithBackoff(s *Sweeper, region string) error {
+	const maxAttempts = 5
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = s.F(region)
+		if err == nil || !isThrottlingError(err) {
+			return err
+		}
+
+		wait := time.Duration(1<<uint(attempt))*time.Second + time.Duration(rand.Intn(1000))*time.Millisecond
+		log.Printf("[WARN] sweep: %s in %s throttled, retrying in %s", s.Name, region, wait)
+		time.Sleep(wait)
+	}
+	return err
+}
+
+func isThrottlingError(err error) bool {
+	msg := err.Error()
+	for _, sub := range []string{"Throttling", "RequestLimitExceeded", "TooManyRequestsException", "rate exceeded"} {
+		if strings.Contains(msg, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweeperRunOrder topologically sorts the sweepers matching filter (all
+// registered sweepers if filter is empty) by their Dependencies, so that
+// dependencies always appear before the sweepers that need them. A
+// dependency outside filter is still pulled into the run -- and appears in
+// the returned order -- since otherwise a filtered run could start a
+// sweeper before a dependency it declared had actually run, contradicting
+// the ordering guarantee.
+func sweeperRunOrder(filter []string) ([]string, error) {
+	included := map[string]bool{}
+	for name := range sweeperFuncs {
+		if matchesSweepFilter(name, filter) {
+			included[name] = true
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var order []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("sweeper dependency cycle detected at %q", name)
+		}
+
+		s, ok := sweeperFuncs[name]
+		if !ok {
+			return fmt.Errorf("unknown sweeper dependency %q", name)
+		}
+
+		state[name] = visiting
+		for _, dep := range s.Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+
+		// Every sweeper we visit is either explicitly included or a
+		// dependency that an included sweeper needs to have finished
+		// first, so it always belongs in the run.
+		order = append(order, name)
+		return nil
+	}
+
+	names := make([]string, 0, len(sweeperFuncs))
+	for name := range sweeperFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if included[name] {
+			if err := visit(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return order, nil
+}
+
+func matchesSweepFilter(name string, filter []string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		if strings.Contains(name, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package resource
+
+import (
+	"testing"
+)
+
+func resetSweepers() {
+	sweeperFuncs = map[string]*Sweeper{}
+	sweeperDefaultRegions = nil
+}
+
+func TestSweeperRunOrder_dependenciesFirst(t *testing.T) {
+	resetSweepers()
+	defer resetSweepers()
+
+	AddTestSweepers("aws_subnet", &Sweeper{F: func(string) error { return nil }})
+	AddTestSweepers("aws_vpc", &Sweeper{
+		Dependencies: []string{"aws_subnet"},
+		F:            func(string) error { return nil },
+	})
+
+	order, err := sweeperRunOrder(nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	pos := map[string]int{}
+	for i, name := range order {
+		pos[name] = i
+	}
+	if pos["aws_subnet"] >= pos["aws_vpc"] {
+		t.Fatalf("expected aws_subnet before aws_vpc, got order %v", order)
+	}
+}
+
+func TestSweeperRunOrder_detectsCycle(t *testing.T) {
+	resetSweepers()
+	defer resetSweepers()
+
+	AddTestSweepers("a", &Sweeper{Dependencies: []string{"b"}, F: func(string) error { return nil }})
+	AddTestSweepers("b", &Sweeper{Dependencies: []string{"a"}, F: func(string) error { return nil }})
+
+	if _, err := sweeperRunOrder(nil); err == nil {
+		t.Fatal("expected a dependency cycle error")
+	}
+}
+
+func TestSweeperRunOrder_filterPullsInDependencies(t *testing.T) {
+	resetSweepers()
+	defer resetSweepers()
+
+	AddTestSweepers("aws_subnet", &Sweeper{F: func(string) error { return nil }})
+	AddTestSweepers("aws_vpc", &Sweeper{
+		Dependencies: []string{"aws_subnet"},
+		F:            func(string) error { return nil },
+	})
+	AddTestSweepers("aws_key_pair", &Sweeper{F: func(string) error { return nil }})
+
+	// Filtering to just "aws_vpc" must still pull in and run its
+	// "aws_subnet" dependency first, even though the filter doesn't
+	// otherwise select it -- and must not pull in unrelated sweepers.
+	order, err := sweeperRunOrder([]string{"aws_vpc"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(order) != 2 {
+		t.Fatalf("expected exactly the vpc sweeper and its dependency, got %v", order)
+	}
+	if order[0] != "aws_subnet" || order[1] != "aws_vpc" {
+		t.Fatalf("expected [aws_subnet aws_vpc], got %v", order)
+	}
+}